@@ -0,0 +1,238 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ruleInfo is the JSON representation of a compiled rule returned by
+// `GET /rules` and `POST /rules` on the `Engine.AdminHandler`.
+type ruleInfo struct {
+	Index     int        `json:"index"`
+	Source    string     `json:"source"`
+	Pattern   string     `json:"pattern"`
+	Target    string     `json:"target"`
+	Code      int        `json:"code,omitempty"`
+	Proxy     bool       `json:"proxy,omitempty"`
+	Hits      int64      `json:"hits"`
+	LastMatch *time.Time `json:"lastMatch,omitempty"`
+}
+
+func (r *redirectMatch) info(index int) ruleInfo {
+	info := ruleInfo{
+		Index:   index,
+		Source:  r.raw,
+		Pattern: r.pattern.String(),
+		Target:  r.target,
+		Code:    r.code,
+		Proxy:   r.isProxy,
+		Hits:    r.hits.Load(),
+	}
+
+	if ns := r.lastMatchUnixNano.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		info.LastMatch = &t
+	}
+
+	return info
+}
+
+// testMatchResult is the JSON response of `POST /test` on the
+// `Engine.AdminHandler`.
+type testMatchResult struct {
+	Matched   bool   `json:"matched"`
+	RuleIndex int    `json:"ruleIndex,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Code      int    `json:"code,omitempty"`
+	Proxy     bool   `json:"proxy,omitempty"`
+}
+
+// testMatch reports which rule (if any) would apply to "rawURL", and its
+// resolved target, without actually serving or redirecting the request.
+// It shares `Engine.resolve` with `rewrite`, the method that actually serves
+// requests, so this can never report an outcome (including the ForceHTTPS
+// target-scheme rewrite or a PrimarySubdomain redirect) that the engine
+// wouldn't actually produce for the same request.
+func (e *Engine) testMatch(rawURL string) (testMatchResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return testMatchResult{}, fmt.Errorf("rewrite: admin: invalid url: %w", err)
+	}
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}
+
+	result := e.resolve(req)
+
+	switch result.kind {
+	case matchForceHTTPS, matchPrimarySubdomain:
+		return testMatchResult{
+			Matched:   true,
+			RuleIndex: -1,
+			Target:    result.target,
+			Code:      result.code,
+		}, nil
+	case matchRedirect, matchRewrite, matchProxy:
+		return testMatchResult{
+			Matched:   true,
+			RuleIndex: result.ruleIndex,
+			Pattern:   result.rule.pattern.String(),
+			Target:    result.target,
+			Code:      result.rule.code,
+			Proxy:     result.rule.isProxy,
+		}, nil
+	default: // matchNone, matchNoop
+		return testMatchResult{Matched: false}, nil
+	}
+}
+
+// addRule parses "line" and appends it to the Engine's rule set,
+// atomically swapping it in the same way `Reload` does.
+func (e *Engine) addRule(line string) (ruleInfo, error) {
+	rd, err := parseRedirectMatchLine(line)
+	if err != nil {
+		return ruleInfo{}, err
+	}
+	rd.raw = line
+
+	e.adminMu.Lock()
+	defer e.adminMu.Unlock()
+
+	current := e.currentRedirects()
+	index := len(current)
+	updated := make([]*redirectMatch, index+1)
+	copy(updated, current)
+	updated[index] = rd
+	e.redirects.Store(&updated)
+
+	return rd.info(index), nil
+}
+
+// removeRule removes the rule at "index" from the Engine's rule set,
+// atomically swapping it in the same way `Reload` does.
+func (e *Engine) removeRule(index int) error {
+	e.adminMu.Lock()
+	defer e.adminMu.Unlock()
+
+	current := e.currentRedirects()
+	if index < 0 || index >= len(current) {
+		return fmt.Errorf("rewrite: admin: no such rule: %d", index)
+	}
+
+	updated := make([]*redirectMatch, 0, len(current)-1)
+	updated = append(updated, current[:index]...)
+	updated = append(updated, current[index+1:]...)
+	e.redirects.Store(&updated)
+
+	return nil
+}
+
+// AdminHandler returns an `http.Handler` exposing a small JSON API to
+// inspect, test and mutate the Engine's rule set at runtime:
+//
+//	GET    /rules      list compiled rules with their source, hit count and last-match time.
+//	POST   /rules      append a new rule, body: {"rule": "301 /seo/(.*) /$1"}.
+//	DELETE /rules/{id} remove the rule at that index.
+//	POST   /test       body: {"url": "..."}, reports which rule (if any) would match.
+//
+// It shares the same atomic rule-set swap, and the same `adminMu` lock, that
+// `Reload` and `LoadWatch` use, so it's safe to mount alongside either of
+// them without losing a concurrent update. It is not mounted automatically;
+// register it under whatever path your own router prefers, normally guarded
+// behind authentication.
+func (e *Engine) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rules", e.handleAdminRules)
+	mux.HandleFunc("/rules/", e.handleAdminRule)
+	mux.HandleFunc("/test", e.handleAdminTest)
+	return mux
+}
+
+func (e *Engine) handleAdminRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		redirects := e.currentRedirects()
+		infos := make([]ruleInfo, len(redirects))
+		for i, rd := range redirects {
+			infos[i] = rd.info(i)
+		}
+		writeAdminJSON(w, http.StatusOK, infos)
+	case http.MethodPost:
+		var body struct {
+			Rule string `json:"rule"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		info, err := e.addRule(body.Rule)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		writeAdminJSON(w, http.StatusCreated, info)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (e *Engine) handleAdminRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := strings.TrimPrefix(r.URL.Path, "/rules/")
+	index, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := e.removeRule(index); err != nil {
+		writeAdminError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleAdminTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := e.testMatch(body.URL)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, result)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,104 @@
+package rewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminHandlerRulesLifecycle(t *testing.T) {
+	engine, err := New(Options{RedirectMatch: []string{"301 /old /new"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	mux := engine.AdminHandler()
+
+	// GET /rules lists the initial rule set.
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rules", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /rules: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var infos []ruleInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("decode GET /rules response: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Pattern == "" {
+		t.Fatalf("GET /rules = %+v, want one rule", infos)
+	}
+
+	// POST /rules appends a new rule.
+	body, _ := json.Marshal(map[string]string{"rule": "302 /a /b"})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rules", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /rules: status = %d, want %d, body: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var created ruleInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode POST /rules response: %v", err)
+	}
+	if created.Index != 1 {
+		t.Fatalf("created.Index = %d, want 1", created.Index)
+	}
+
+	if got := len(engine.currentRedirects()); got != 2 {
+		t.Fatalf("currentRedirects() = %d rules, want 2", got)
+	}
+
+	// DELETE /rules/0 removes the first rule.
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/rules/0", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /rules/0: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	redirects := engine.currentRedirects()
+	if len(redirects) != 1 {
+		t.Fatalf("currentRedirects() = %d rules after delete, want 1", len(redirects))
+	}
+	if redirects[0].target != "/b" {
+		t.Fatalf("remaining rule target = %q, want %q", redirects[0].target, "/b")
+	}
+
+	// DELETE an out-of-range index reports an error, doesn't remove anything.
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/rules/99", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE /rules/99: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminHandlerTestMatch(t *testing.T) {
+	engine, err := New(Options{
+		ForceHTTPS:    true,
+		RedirectMatch: []string{"301 /seo/(.*) http://example.com/new/$1"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	mux := engine.AdminHandler()
+
+	body, _ := json.Marshal(map[string]string{"url": "https://example.com/seo/foo"})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /test: status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var result testMatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode POST /test response: %v", err)
+	}
+
+	if !result.Matched {
+		t.Fatalf("Matched = false, want true")
+	}
+	if want := "https://example.com/new/foo"; result.Target != want {
+		t.Errorf("Target = %q, want %q (the ForceHTTPS target-scheme rewrite must be reflected, matching what the engine would actually serve)", result.Target, want)
+	}
+}
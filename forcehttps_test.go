@@ -0,0 +1,82 @@
+package rewrite
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForceHTTPSRedirectsWithoutHSTSOnPlainRequest(t *testing.T) {
+	engine, err := New(Options{
+		ForceHTTPS: true,
+		HSTS:       &HSTSConfig{MaxAge: 31536000, IncludeSubdomains: true},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/path", nil)
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security must not be sent over plain HTTP, got %q", got)
+	}
+
+	if loc := w.Header().Get("Location"); loc != "https://example.com/path" {
+		t.Errorf("Location = %q, want %q", loc, "https://example.com/path")
+	}
+
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+	}
+}
+
+func TestHSTSSentOnlyOnHTTPSRedirect(t *testing.T) {
+	engine, err := New(Options{
+		ForceHTTPS:    true,
+		HSTS:          &HSTSConfig{MaxAge: 63072000},
+		RedirectMatch: []string{"301 /old /new"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/old", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	want := "max-age=63072000"
+	if got := w.Header().Get("Strict-Transport-Security"); got != want {
+		t.Errorf("Strict-Transport-Security = %q, want %q", got, want)
+	}
+
+	if loc := w.Header().Get("Location"); loc != "/new" {
+		t.Errorf("Location = %q, want %q", loc, "/new")
+	}
+}
+
+func TestForceHTTPSRewritesHTTPTargetScheme(t *testing.T) {
+	engine, err := New(Options{
+		ForceHTTPS:    true,
+		RedirectMatch: []string{"301 /seo/(.*) http://example.com/new/$1"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/seo/foo", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	want := "https://example.com/new/foo"
+	if loc := w.Header().Get("Location"); loc != want {
+		t.Errorf("Location = %q, want %q", loc, want)
+	}
+}
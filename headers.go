@@ -0,0 +1,197 @@
+package rewrite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// headerOp is a single response header or cookie mutation, attached to a
+// redirect rule (see `redirectMatch.headerOps`) or built from
+// `Options.ResponseHeaders` (see `responseHeaderOpsFromMap`).
+type headerOp struct {
+	action string // "set", "add", "remove", "set-cookie" or "remove-cookie".
+	name   string
+	value  string
+}
+
+// headerOpPrefixes maps a rule-line directive prefix, e.g. "set-header",
+// to the `headerOp` action it produces.
+var headerOpPrefixes = map[string]string{
+	"set-header":    "set",
+	"add-header":    "add",
+	"remove-header": "remove",
+	"set-cookie":    "set-cookie",
+	"remove-cookie": "remove-cookie",
+}
+
+// parseHeaderOp parses a single rule-line modifier field such as
+// "set-header:X-Api-Version=2" or "remove-header:Server" into a `headerOp`.
+// It returns ok=false (no error) when "field" doesn't use a known prefix,
+// so the caller can fall back to treating it as a condition.
+func parseHeaderOp(field string) (headerOp, bool, error) {
+	prefix, rest, ok := strings.Cut(field, ":")
+	if !ok {
+		return headerOp{}, false, nil
+	}
+
+	action, ok := headerOpPrefixes[strings.ToLower(prefix)]
+	if !ok {
+		return headerOp{}, false, nil
+	}
+
+	name, value, _ := strings.Cut(rest, "=")
+	if name == "" {
+		return headerOp{}, false, fmt.Errorf("redirect match: invalid header directive: %s", field)
+	}
+
+	return headerOp{action: action, name: name, value: value}, true, nil
+}
+
+// responseHeaderOpsFromMap turns `Options.ResponseHeaders` into "set" header ops.
+func responseHeaderOpsFromMap(headers map[string]string) []headerOp {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	ops := make([]headerOp, 0, len(headers))
+	for name, value := range headers {
+		ops = append(ops, headerOp{action: "set", name: name, value: value})
+	}
+
+	return ops
+}
+
+// applyHeaderOps applies "ops", in order, to "h".
+func applyHeaderOps(h http.Header, ops []headerOp) {
+	for _, op := range ops {
+		switch op.action {
+		case "set":
+			h.Set(op.name, op.value)
+		case "add":
+			h.Add(op.name, op.value)
+		case "remove":
+			h.Del(op.name)
+		case "set-cookie":
+			h.Add("Set-Cookie", (&http.Cookie{Name: op.name, Value: op.value, Path: "/"}).String())
+		case "remove-cookie":
+			h.Add("Set-Cookie", (&http.Cookie{Name: op.name, Value: "", Path: "/", MaxAge: -1}).String())
+		}
+	}
+}
+
+// wrapWriter returns "w" wrapped so that the combination of the Engine's
+// global `Options.ResponseHeaders` and "ruleOps" is applied right before
+// the first WriteHeader/Write call, so it lands on the downstream handler's
+// (or reverse proxy's) response instead of being silently overwritten by it.
+// It returns "w" unchanged when there is nothing to apply.
+func (e *Engine) wrapWriter(w http.ResponseWriter, ruleOps []headerOp) http.ResponseWriter {
+	if len(e.responseHeaderOps) == 0 && len(ruleOps) == 0 {
+		return w
+	}
+
+	ops := make([]headerOp, 0, len(e.responseHeaderOps)+len(ruleOps))
+	ops = append(ops, e.responseHeaderOps...)
+	ops = append(ops, ruleOps...)
+
+	return &headerModifierWriter{ResponseWriter: w, ops: ops}
+}
+
+// headerModifierWriter defers "ops" until WriteHeader (or the first Write,
+// for handlers that skip an explicit WriteHeader call) fires.
+//
+// It forwards the optional interfaces ("http.Flusher", "http.Hijacker",
+// "http.CloseNotifier" and "io.ReaderFrom") to the wrapped
+// `http.ResponseWriter` instead of only embedding it: a bare embedding
+// promotes only the `http.ResponseWriter` methods, so the *wrapper* type
+// would not satisfy those interfaces at all - silently breaking WebSocket
+// upgrades (`Hijack`) and streaming/SSE responses (`Flush`) for any handler
+// or `proxy` rule behind it the moment `wrapWriter` decides to wrap at all.
+// The wrapper always implements them; each method delegates when the
+// wrapped writer supports it and otherwise falls back to a no-op, an error
+// or a plain copy.
+type headerModifierWriter struct {
+	http.ResponseWriter
+	ops  []headerOp
+	done bool
+}
+
+// WriteHeader implements the `http.ResponseWriter` interface.
+func (w *headerModifierWriter) WriteHeader(statusCode int) {
+	w.applyOnce()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements the `http.ResponseWriter` interface.
+func (w *headerModifierWriter) Write(b []byte) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerModifierWriter) applyOnce() {
+	if !w.done {
+		applyHeaderOps(w.ResponseWriter.Header(), w.ops)
+		w.done = true
+	}
+}
+
+// Flush implements `http.Flusher`, delegating only if the wrapped writer
+// supports it; a no-op otherwise, same as a plain `http.ResponseWriter`
+// that doesn't implement it.
+func (w *headerModifierWriter) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	w.applyOnce()
+	flusher.Flush()
+}
+
+// Hijack implements `http.Hijacker`, delegating to the wrapped writer. It
+// errors the same way a writer that doesn't support hijacking would be
+// reported to a caller that type-asserts for `http.Hijacker` first.
+func (w *headerModifierWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("rewrite: underlying ResponseWriter does not implement http.Hijacker")
+	}
+
+	return hijacker.Hijack()
+}
+
+// CloseNotify implements the deprecated `http.CloseNotifier`, delegating
+// only if the wrapped writer supports it, for handlers that still rely on
+// it instead of `Request.Context()`.
+func (w *headerModifierWriter) CloseNotify() <-chan bool {
+	notifier, ok := w.ResponseWriter.(http.CloseNotifier) //nolint:staticcheck // forwarding, not using it ourselves.
+	if !ok {
+		return nil
+	}
+
+	return notifier.CloseNotify()
+}
+
+// ReadFrom implements `io.ReaderFrom`, delegating only if the wrapped
+// writer supports it, so a reverse proxy copying a response body doesn't
+// lose the underlying writer's fast path (e.g. `net/http`'s sendfile-backed
+// ReadFrom) just because a header op is attached.
+func (w *headerModifierWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.applyOnce()
+
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+
+	return io.Copy(writerOnly{w}, src)
+}
+
+// writerOnly hides any other interface "w" implements, in particular
+// `io.ReaderFrom`, so `io.Copy` in `ReadFrom`'s fallback path always takes
+// the plain read/write loop instead of calling back into `ReadFrom` itself.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,69 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeadersAppliedOnRedirect(t *testing.T) {
+	engine, err := New(Options{
+		ResponseHeaders: map[string]string{"X-Api-Version": "2"},
+		RedirectMatch:   []string{"301 /old /new"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Api-Version"); got != "2" {
+		t.Errorf("X-Api-Version = %q, want %q", got, "2")
+	}
+
+	if loc := w.Header().Get("Location"); loc != "/new" {
+		t.Errorf("Location = %q, want %q", loc, "/new")
+	}
+}
+
+func TestResponseHeadersAppliedOnPassthrough(t *testing.T) {
+	engine, err := New(Options{
+		ResponseHeaders: map[string]string{"X-Api-Version": "2"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/untouched", nil)
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Api-Version"); got != "2" {
+		t.Errorf("X-Api-Version = %q, want %q", got, "2")
+	}
+}
+
+func TestRuleHeaderOpsOverrideGlobalResponseHeaders(t *testing.T) {
+	engine, err := New(Options{
+		ResponseHeaders: map[string]string{"X-Api-Version": "2"},
+		RedirectMatch:   []string{"301 /old /new set-header:X-Api-Version=3"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	w := httptest.NewRecorder()
+
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Api-Version"); got != "3" {
+		t.Errorf("X-Api-Version = %q, want %q (rule-specific op should win)", got, "3")
+	}
+}
@@ -0,0 +1,232 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogEventType describes which kind of action through the rewrite Engine
+// produced a `LogEvent`.
+type LogEventType string
+
+const (
+	// LogEventRedirect is recorded when a rule sent back a 3xx redirect response.
+	LogEventRedirect LogEventType = "redirect"
+	// LogEventRewrite is recorded when a rule handled the request internally,
+	// without a redirect response: a `code=0` rewrite or a `proxy` rule.
+	LogEventRewrite LogEventType = "rewrite"
+	// LogEventSkip is recorded when no rule matched (or a matched rule was a no-op)
+	// and the request fell through to the next handler as-is.
+	LogEventSkip LogEventType = "skip"
+)
+
+// LogEvent is the structured record a `Logger` receives for a single request
+// pass through the rewrite Engine.
+type LogEvent struct {
+	Type      LogEventType  `json:"type"`
+	Method    string        `json:"method"`
+	URL       string        `json:"url"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Target    string        `json:"target,omitempty"`
+	Code      int           `json:"code,omitempty"`
+	RuleIndex int           `json:"ruleIndex"` // -1 on a skip event, including a no-op match.
+	Latency   time.Duration `json:"latency"`
+}
+
+// Logger is implemented by structured access/redirect log sinks.
+// Use `NewJSONLogger` or `NewLogfmtLogger` for the built-in encoders,
+// wrap one in a `FilterLogger` to drop fields or sample,
+// and attach it via `Engine.SetStructuredLogger` or `Options.AccessLog`.
+type Logger interface {
+	LogRedirect(event LogEvent)
+	LogRewrite(event LogEvent)
+	LogSkip(event LogEvent)
+}
+
+// Encoder turns a single `LogEvent` into one line of output, written to "w".
+type Encoder interface {
+	Encode(w io.Writer, event LogEvent) error
+}
+
+// JSONEncoder encodes each LogEvent as a single line of JSON.
+type JSONEncoder struct{}
+
+// Encode implements the `Encoder` interface.
+func (JSONEncoder) Encode(w io.Writer, event LogEvent) error {
+	return json.NewEncoder(w).Encode(event)
+}
+
+// LogfmtEncoder encodes each LogEvent as a single line of logfmt (key=value) pairs.
+type LogfmtEncoder struct{}
+
+// Encode implements the `Encoder` interface.
+func (LogfmtEncoder) Encode(w io.Writer, event LogEvent) error {
+	_, err := fmt.Fprintf(w, "type=%s method=%s url=%q pattern=%q target=%q code=%d ruleIndex=%d latency=%s\n",
+		event.Type, event.Method, event.URL, event.Pattern, event.Target, event.Code, event.RuleIndex, event.Latency)
+	return err
+}
+
+// encoderLogger is a `Logger` that serializes every event, through an
+// `Encoder`, to an `io.Writer`, guarded by a mutex since requests are
+// served concurrently.
+type encoderLogger struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder Encoder
+}
+
+// NewJSONLogger returns a `Logger` that writes one JSON line per event to "w".
+func NewJSONLogger(w io.Writer) Logger {
+	return &encoderLogger{w: w, encoder: JSONEncoder{}}
+}
+
+// NewLogfmtLogger returns a `Logger` that writes one logfmt line per event to "w".
+func NewLogfmtLogger(w io.Writer) Logger {
+	return &encoderLogger{w: w, encoder: LogfmtEncoder{}}
+}
+
+func (l *encoderLogger) log(event LogEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.encoder.Encode(l.w, event) // best-effort, a broken access log must not break serving.
+}
+
+// LogRedirect implements the `Logger` interface.
+func (l *encoderLogger) LogRedirect(event LogEvent) { l.log(event) }
+
+// LogRewrite implements the `Logger` interface.
+func (l *encoderLogger) LogRewrite(event LogEvent) { l.log(event) }
+
+// LogSkip implements the `Logger` interface.
+func (l *encoderLogger) LogSkip(event LogEvent) { l.log(event) }
+
+// FilterLogger wraps another `Logger`, optionally blanking out named fields
+// before they reach it and/or sampling only a fraction of events.
+type FilterLogger struct {
+	// Logger is the wrapped sink, required.
+	Logger Logger
+	// DropFields lists `LogEvent` JSON field names (e.g. "url", "target")
+	// to blank out before encoding.
+	DropFields []string
+	// SampleRate keeps only a random fraction of events, in (0, 1].
+	// Zero or >= 1 means every event is kept.
+	SampleRate float64
+}
+
+func (f *FilterLogger) shouldLog() bool {
+	if f.SampleRate <= 0 || f.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < f.SampleRate
+}
+
+func (f *FilterLogger) apply(event LogEvent) LogEvent {
+	for _, field := range f.DropFields {
+		switch field {
+		case "url":
+			event.URL = ""
+		case "pattern":
+			event.Pattern = ""
+		case "target":
+			event.Target = ""
+		case "code":
+			event.Code = 0
+		case "latency":
+			event.Latency = 0
+		}
+	}
+
+	return event
+}
+
+// LogRedirect implements the `Logger` interface.
+func (f *FilterLogger) LogRedirect(event LogEvent) {
+	if f.shouldLog() {
+		f.Logger.LogRedirect(f.apply(event))
+	}
+}
+
+// LogRewrite implements the `Logger` interface.
+func (f *FilterLogger) LogRewrite(event LogEvent) {
+	if f.shouldLog() {
+		f.Logger.LogRewrite(f.apply(event))
+	}
+}
+
+// LogSkip implements the `Logger` interface.
+func (f *FilterLogger) LogSkip(event LogEvent) {
+	if f.shouldLog() {
+		f.Logger.LogSkip(f.apply(event))
+	}
+}
+
+// openAccessLogWriter resolves `Options.AccessLog` to an `io.Writer`:
+// "stdout"/"stderr" map to the standard streams, anything else is treated
+// as a file path to append to.
+func openAccessLogWriter(accessLog string) (io.Writer, error) {
+	switch accessLog {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	default:
+		f, err := os.OpenFile(accessLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite: access log: %w", err)
+		}
+		return f, nil
+	}
+}
+
+func (e *Engine) logRedirect(r *http.Request, rd *redirectMatch, ruleIndex int, src, target string, start time.Time) {
+	if e.structuredLogger == nil {
+		return
+	}
+
+	e.structuredLogger.LogRedirect(LogEvent{
+		Type:      LogEventRedirect,
+		Method:    r.Method,
+		URL:       src,
+		Pattern:   rd.pattern.String(),
+		Target:    target,
+		Code:      rd.code,
+		RuleIndex: ruleIndex,
+		Latency:   time.Since(start),
+	})
+}
+
+func (e *Engine) logRewrite(r *http.Request, rd *redirectMatch, ruleIndex int, src, target string, start time.Time) {
+	if e.structuredLogger == nil {
+		return
+	}
+
+	e.structuredLogger.LogRewrite(LogEvent{
+		Type:      LogEventRewrite,
+		Method:    r.Method,
+		URL:       src,
+		Pattern:   rd.pattern.String(),
+		Target:    target,
+		RuleIndex: ruleIndex,
+		Latency:   time.Since(start),
+	})
+}
+
+func (e *Engine) logSkip(r *http.Request, start time.Time) {
+	if e.structuredLogger == nil {
+		return
+	}
+
+	e.structuredLogger.LogSkip(LogEvent{
+		Type:      LogEventSkip,
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		RuleIndex: -1,
+		Latency:   time.Since(start),
+	})
+}
@@ -0,0 +1,142 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordingLogger is a `Logger` that keeps every event it receives, for
+// assertions.
+type recordingLogger struct {
+	events []LogEvent
+}
+
+func (l *recordingLogger) LogRedirect(event LogEvent) { l.events = append(l.events, event) }
+func (l *recordingLogger) LogRewrite(event LogEvent)  { l.events = append(l.events, event) }
+func (l *recordingLogger) LogSkip(event LogEvent)     { l.events = append(l.events, event) }
+
+func TestLogRedirectEvent(t *testing.T) {
+	engine, err := New(Options{RedirectMatch: []string{"301 /old /new"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	engine.SetStructuredLogger(logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	w := httptest.NewRecorder()
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if len(logger.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(logger.events))
+	}
+
+	event := logger.events[0]
+	if event.Type != LogEventRedirect {
+		t.Errorf("Type = %q, want %q", event.Type, LogEventRedirect)
+	}
+	if event.URL != "/old" {
+		t.Errorf("URL = %q, want %q", event.URL, "/old")
+	}
+	if event.Target != "/new" {
+		t.Errorf("Target = %q, want %q", event.Target, "/new")
+	}
+	if event.Code != http.StatusMovedPermanently {
+		t.Errorf("Code = %d, want %d", event.Code, http.StatusMovedPermanently)
+	}
+	if event.RuleIndex != 0 {
+		t.Errorf("RuleIndex = %d, want 0", event.RuleIndex)
+	}
+}
+
+// slowHandler sleeps for "delay" before responding, so the logged latency
+// for a skip/rewrite/proxy event can be asserted to include it.
+func slowHandler(delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestLogSkipEventIncludesDownstreamLatency(t *testing.T) {
+	engine, err := New(Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	engine.SetStructuredLogger(logger)
+
+	const delay = 20 * time.Millisecond
+	req := httptest.NewRequest(http.MethodGet, "/anything", nil)
+	w := httptest.NewRecorder()
+	engine.Handler(slowHandler(delay)).ServeHTTP(w, req)
+
+	if len(logger.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(logger.events))
+	}
+
+	event := logger.events[0]
+	if event.Type != LogEventSkip {
+		t.Errorf("Type = %q, want %q", event.Type, LogEventSkip)
+	}
+	if event.Latency < delay {
+		t.Errorf("Latency = %s, want at least %s (downstream handler's delay)", event.Latency, delay)
+	}
+}
+
+func TestLogRewriteEventIncludesDownstreamLatency(t *testing.T) {
+	engine, err := New(Options{RedirectMatch: []string{"0 /old /new"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	engine.SetStructuredLogger(logger)
+
+	const delay = 20 * time.Millisecond
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	w := httptest.NewRecorder()
+	engine.Handler(slowHandler(delay)).ServeHTTP(w, req)
+
+	if len(logger.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(logger.events))
+	}
+
+	event := logger.events[0]
+	if event.Type != LogEventRewrite {
+		t.Errorf("Type = %q, want %q", event.Type, LogEventRewrite)
+	}
+	if event.Latency < delay {
+		t.Errorf("Latency = %s, want at least %s (downstream handler's delay)", event.Latency, delay)
+	}
+}
+
+func TestFilterLoggerDropsFieldsAndSamples(t *testing.T) {
+	inner := &recordingLogger{}
+	filter := &FilterLogger{Logger: inner, DropFields: []string{"target", "code"}}
+
+	filter.LogRedirect(LogEvent{Type: LogEventRedirect, URL: "/old", Target: "/new", Code: 301})
+
+	if len(inner.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(inner.events))
+	}
+	if inner.events[0].Target != "" {
+		t.Errorf("Target = %q, want dropped (empty)", inner.events[0].Target)
+	}
+	if inner.events[0].Code != 0 {
+		t.Errorf("Code = %d, want dropped (0)", inner.events[0].Code)
+	}
+	if inner.events[0].URL != "/old" {
+		t.Errorf("URL = %q, want %q (not in DropFields)", inner.events[0].URL, "/old")
+	}
+
+	zero := &FilterLogger{Logger: inner, SampleRate: 0.0 /* treated as "keep every event" */}
+	zero.LogSkip(LogEvent{Type: LogEventSkip})
+	if len(inner.events) != 2 {
+		t.Errorf("SampleRate=0 should keep every event, got %d events, want 2", len(inner.events))
+	}
+}
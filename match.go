@@ -0,0 +1,139 @@
+package rewrite
+
+import (
+	"net/http"
+	"strings"
+)
+
+// matchKind describes the action `Engine.resolve` decided on for a request.
+type matchKind int
+
+const (
+	// matchNone means no rule, nor ForceHTTPS/PrimarySubdomain, applied;
+	// the request should fall through to the next handler as-is.
+	matchNone matchKind = iota
+	// matchNoop means a rule matched but its resolved target equals its
+	// source, so no redirect is actually sent; the request still falls
+	// through to the next handler.
+	matchNoop
+	// matchForceHTTPS means the request arrived over plain HTTP and must be
+	// redirected to its HTTPS equivalent, see `Options.ForceHTTPS`.
+	matchForceHTTPS
+	// matchPrimarySubdomain means the request hit the root domain and must
+	// be redirected to `Options.PrimarySubdomain`.
+	matchPrimarySubdomain
+	// matchRedirect means a rule matched and issues a 3xx redirect.
+	matchRedirect
+	// matchRewrite means a `code<=0` rule matched: handled internally, no
+	// redirect response is sent.
+	matchRewrite
+	// matchProxy means a `proxy` rule matched: the request is forwarded to
+	// an upstream instead of redirected.
+	matchProxy
+)
+
+// matchResult is the outcome of `Engine.resolve`: what the Engine decided to
+// do with a request, without performing any I/O. It is shared by `rewrite`,
+// which executes the decision, and `Engine.testMatch`, which only reports
+// it, so the two can never drift apart.
+type matchResult struct {
+	kind      matchKind
+	src       string
+	target    string
+	code      int
+	ruleIndex int // -1 when no rule is involved (ForceHTTPS, PrimarySubdomain or no match).
+	rule      *redirectMatch
+}
+
+// resolve decides what the Engine would do with "r": redirect it (root
+// domain canonicalization, ForceHTTPS, or a matched rule), handle it
+// internally (rewrite or proxy), or let it fall through untouched. It
+// mutates "r"'s Host/URL in the primary subdomain cases (both the root-domain
+// redirect and the passthrough), but otherwise performs no I/O
+// and records no hits, so it is safe to call from a dry-run path such as
+// `Engine.testMatch`.
+func (e *Engine) resolve(r *http.Request) matchResult {
+	if e.options.ForceHTTPS && r.TLS == nil && getScheme(r) == schemeHTTP+sufscheme {
+		target := schemeHTTPS + sufscheme + getHost(r) + r.URL.RequestURI()
+		return matchResult{kind: matchForceHTTPS, target: target, code: http.StatusMovedPermanently, ruleIndex: -1}
+	}
+
+	if primarySubdomain := e.options.PrimarySubdomain; primarySubdomain != "" {
+		hostport := getHost(r)
+		root := getDomain(hostport)
+
+		e.debugf("Begin request: full host: %s and root domain: %s", hostport, root)
+		// Note:
+		// localhost and 127.0.0.1 are not supported for subdomain rewrite, by purpose,
+		// use a virtual host instead.
+		// GetDomain will return will return localhost or www.localhost
+		// on expected loopbacks.
+		if e.domainValidator(root) {
+			root += getPort(hostport)
+			subdomain := strings.TrimSuffix(hostport, root)
+
+			e.debugf("* Domain is not a loopback, requested subdomain: %s\n", subdomain)
+
+			if subdomain == "" {
+				// we are in root domain, full redirect to its primary subdomain.
+				newHost := primarySubdomain + root
+				r.Host = newHost
+				r.URL.Host = newHost
+				return matchResult{kind: matchPrimarySubdomain, target: r.URL.String(), code: http.StatusMovedPermanently, ruleIndex: -1}
+			}
+
+			if subdomain == primarySubdomain {
+				// keep root domain as the Host field inside the next handlers,
+				// for consistently use and
+				// to bypass the subdomain router (`routeHandler`)
+				// do not return, redirects should be respected.
+				rootHost := strings.TrimPrefix(hostport, subdomain)
+				e.debugf("* Request host field was modified to: %s. Proceed without redirection\n", rootHost)
+				// modify those for the next redirects or the route handler.
+				r.Host = rootHost
+				r.URL.Host = rootHost
+			}
+
+			// maybe other subdomain or not at all, let's continue.
+		} else {
+			e.debugf("* Primary subdomain is: %s but redirect response was not sent. Domain is a loopback?\n", primarySubdomain)
+		}
+	}
+
+	for i, rd := range e.currentRedirects() {
+		if !rd.matchesConditions(r) {
+			continue
+		}
+
+		src := r.URL.Path
+		if !rd.isRelativePattern {
+			// don't change the request, use a full redirect.
+			src = getScheme(r) + getHost(r) + r.URL.RequestURI()
+		}
+
+		target, ok := rd.matchAndReplace(src)
+		if !ok {
+			continue
+		}
+
+		if e.options.ForceHTTPS && !rd.isProxy && strings.HasPrefix(target, schemeHTTP+sufscheme) {
+			target = schemeHTTPS + sufscheme + target[len(schemeHTTP+sufscheme):]
+		}
+
+		if target == src {
+			return matchResult{kind: matchNoop, src: src, ruleIndex: i, rule: rd}
+		}
+
+		kind := matchRedirect
+		switch {
+		case rd.isProxy:
+			kind = matchProxy
+		case rd.noRedirect:
+			kind = matchRewrite
+		}
+
+		return matchResult{kind: kind, src: src, target: target, code: rd.code, ruleIndex: i, rule: rd}
+	}
+
+	return matchResult{kind: matchNone, ruleIndex: -1}
+}
@@ -0,0 +1,99 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNetlifyRedirectLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		src        string
+		wantTarget string
+		wantCode   int
+	}{
+		{
+			name:       "named params and default code",
+			line:       "/blog/:year/:slug /posts/:year-:slug",
+			src:        "/blog/2024/hello",
+			wantTarget: "/posts/2024-hello",
+			wantCode:   http.StatusMovedPermanently,
+		},
+		{
+			name:       "splat and explicit code",
+			line:       "/old/* /new/:splat 302",
+			src:        "/old/a/b",
+			wantTarget: "/new/a/b",
+			wantCode:   http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd, err := parseRedirectMatchLine(tt.line)
+			if err != nil {
+				t.Fatalf("parseRedirectMatchLine(%q): %v", tt.line, err)
+			}
+
+			if rd.code != tt.wantCode {
+				t.Errorf("code = %d, want %d", rd.code, tt.wantCode)
+			}
+
+			got, ok := rd.matchAndReplace(tt.src)
+			if !ok {
+				t.Fatalf("matchAndReplace(%q): no match", tt.src)
+			}
+			if got != tt.wantTarget {
+				t.Errorf("matchAndReplace(%q) = %q, want %q", tt.src, got, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestConditionMatcherLanguage(t *testing.T) {
+	cond, err := parseConditionMatcher("Language=en")
+	if err != nil {
+		t.Fatalf("parseConditionMatcher: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{name: "english first", header: "en-US,fr;q=0.8", want: true},
+		{name: "english not first", header: "fr-FR,en;q=0.5", want: true},
+		{name: "no english", header: "fr-FR,de;q=0.5", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", tt.header)
+
+			if got := cond.matches(req); got != tt.want {
+				t.Errorf("matches(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionMatcherCountryAndRole(t *testing.T) {
+	country, err := parseConditionMatcher("Country=us,ca")
+	if err != nil {
+		t.Fatalf("parseConditionMatcher: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Country", "CA")
+	if !country.matches(req) {
+		t.Errorf("expected Country=us,ca to match X-Country: CA")
+	}
+
+	req.Header.Set("X-Country", "fr")
+	if country.matches(req) {
+		t.Errorf("did not expect Country=us,ca to match X-Country: fr")
+	}
+}
@@ -0,0 +1,73 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+)
+
+// proxyCache caches one `*httputil.ReverseProxy` per unique upstream
+// (scheme + host) so that requests forwarded by `proxy` RedirectMatch
+// rules reuse the same transport and connection pool instead of
+// constructing a new reverse proxy on every request.
+type proxyCache struct {
+	mu    sync.RWMutex
+	byKey map[string]*httputil.ReverseProxy
+}
+
+func newProxyCache() *proxyCache {
+	return &proxyCache{byKey: make(map[string]*httputil.ReverseProxy)}
+}
+
+// get returns the cached reverse proxy for "upstream", creating one on
+// first use. "preserveHost" controls whether the original request's Host
+// header is kept or replaced with the upstream's host.
+func (c *proxyCache) get(upstream *url.URL, preserveHost bool) *httputil.ReverseProxy {
+	key := upstream.Scheme + "://" + upstream.Host
+
+	c.mu.RLock()
+	proxy, ok := c.byKey[key]
+	c.mu.RUnlock()
+	if ok {
+		return proxy
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if proxy, ok = c.byKey[key]; ok { // someone else won the race.
+		return proxy
+	}
+
+	proxy = httputil.NewSingleHostReverseProxy(&url.URL{Scheme: upstream.Scheme, Host: upstream.Host})
+	if !preserveHost {
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Host = upstream.Host
+		}
+	}
+
+	c.byKey[key] = proxy
+	return proxy
+}
+
+// serveProxy forwards "r" to "target" (the already regex-substituted
+// upstream URL of a `proxy` RedirectMatch rule) through the cached reverse
+// proxy for that upstream.
+func (e *Engine) serveProxy(w http.ResponseWriter, r *http.Request, target string) {
+	upstream, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	r.URL.Path = upstream.Path
+	r.URL.RawPath = upstream.RawPath
+	if upstream.RawQuery != "" {
+		r.URL.RawQuery = upstream.RawQuery
+	}
+
+	e.proxies.get(upstream, e.options.PreserveHost).ServeHTTP(w, r)
+}
@@ -0,0 +1,63 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestProxyCacheGetReusesProxy(t *testing.T) {
+	cache := newProxyCache()
+	upstream, err := url.Parse("http://backend:9000")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	p1 := cache.get(upstream, false)
+	p2 := cache.get(upstream, false)
+	if p1 != p2 {
+		t.Errorf("expected the cached *httputil.ReverseProxy to be reused for the same upstream")
+	}
+}
+
+func TestServeProxyHostHandling(t *testing.T) {
+	var gotHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	upstreamHost := upstream.Listener.Addr().String()
+
+	tests := []struct {
+		name         string
+		preserveHost bool
+		wantHost     string
+	}{
+		{name: "replaces host with upstream by default", preserveHost: false, wantHost: upstreamHost},
+		{name: "keeps original host when PreserveHost is set", preserveHost: true, wantHost: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := New(Options{
+				PreserveHost:  tt.preserveHost,
+				RedirectMatch: []string{"proxy /api/(.*) " + upstream.URL + "/$1"},
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/users", nil)
+			w := httptest.NewRecorder()
+
+			engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+			if gotHost != tt.wantHost {
+				t.Errorf("upstream saw Host=%q, want %q", gotHost, tt.wantHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package rewrite
+
+import (
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchConfig holds the tunables `LoadWatch` accepts through `WatchOption`.
+type watchConfig struct {
+	debounce time.Duration
+}
+
+// WatchOption customizes `LoadWatch`.
+type WatchOption func(*watchConfig)
+
+// WithDebounce sets the minimum delay `LoadWatch` waits, after seeing a
+// change, before reloading - coalescing the burst of fsnotify events a
+// single file save usually produces. Defaults to 100ms.
+func WithDebounce(d time.Duration) WatchOption {
+	return func(c *watchConfig) {
+		c.debounce = d
+	}
+}
+
+// LoadWatch behaves like `Load`, but also watches "filename" with fsnotify
+// and reloads the Engine's rule set (see `Engine.ReloadFile`) whenever it
+// changes. Parse errors are logged through the Engine's logger and leave
+// the previous rule set in place instead of panicking, since by this point
+// the server is already running.
+// It panics if "filename" can't be loaded initially or can't be watched.
+//
+// Usage:
+//
+//	redirects := rewrite.LoadWatch("redirects.yml")
+//	http.ListenAndServe(":8080", redirects(router))
+func LoadWatch(filename string, opts ...WatchOption) func(http.Handler) http.Handler {
+	cfg := watchConfig{debounce: 100 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	loadOpts, err := LoadOptions(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	engine, err := New(loadOpts)
+	if err != nil {
+		panic(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		panic(err)
+	}
+
+	// Watch the containing directory, not the file itself: editors and
+	// deploy tools commonly replace a file via rename instead of writing
+	// to it in place, which a direct watch on "filename" would miss.
+	if err := watcher.Add(filepath.Dir(filename)); err != nil {
+		panic(err)
+	}
+
+	go engine.watchFile(watcher, filename, cfg.debounce)
+
+	return engine.Handler
+}
+
+// watchFile drains "watcher" for changes to "filename" and reloads the
+// Engine's rule set, debounced by "debounce", until the watcher is closed.
+func (e *Engine) watchFile(watcher *fsnotify.Watcher, filename string, debounce time.Duration) {
+	defer watcher.Close()
+
+	target := filepath.Clean(filename)
+	var timer *time.Timer
+
+	reload := func() {
+		if err := e.ReloadFile(filename); err != nil {
+			e.logger.Printf("rewrite: reload: %v", err)
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(debounce, reload)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			e.logger.Printf("rewrite: watch: %v", err)
+		}
+	}
+}
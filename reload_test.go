@@ -0,0 +1,69 @@
+package rewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadWatchReloadsOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "redirects.yml")
+
+	write := func(line string) {
+		content := "RedirectMatch:\n  - \"" + line + "\"\n"
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	write("301 /old /first")
+
+	middleware := LoadWatch(filename, WithDebounce(10*time.Millisecond))
+	handler := middleware(http.NotFoundHandler())
+
+	location := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/old", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Header().Get("Location")
+	}
+
+	if loc := location(); loc != "/first" {
+		t.Fatalf("Location = %q, want %q", loc, "/first")
+	}
+
+	write("301 /old /second")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if loc := location(); loc == "/second" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("LoadWatch did not pick up the file change within the deadline")
+}
+
+func TestReloadReplacesRuleSetAtomically(t *testing.T) {
+	engine, err := New(Options{RedirectMatch: []string{"301 /old /first"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := engine.Reload(Options{RedirectMatch: []string{"301 /old /second"}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	w := httptest.NewRecorder()
+	engine.Handler(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if loc := w.Header().Get("Location"); loc != "/second" {
+		t.Errorf("Location = %q, want %q", loc, "/second")
+	}
+}
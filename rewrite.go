@@ -1,6 +1,7 @@
 package rewrite
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -8,9 +9,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/publicsuffix"
 	"gopkg.in/yaml.v3"
@@ -34,11 +39,68 @@ type Options struct {
 	// will be used to serve the requests.
 	PrimarySubdomain string `json:"primarySubdomain" yaml:"PrimarySubdomain"`
 
+	// PreserveHost, when true, keeps the original request's Host header
+	// on requests forwarded through a `proxy` RedirectMatch rule instead of
+	// replacing it with the upstream's host.
+	PreserveHost bool `json:"preserveHost" yaml:"PreserveHost"`
+
+	// ForceHTTPS, when true, redirects any plain HTTP request to its HTTPS
+	// equivalent before any `RedirectMatch` rule is evaluated, and rewrites
+	// any "http://" scheme in a matched rule's resolved target to "https://".
+	// The upstream of a `proxy` rule is left as it is.
+	// See the `Serve` package-level function, which sets this automatically.
+	ForceHTTPS bool `json:"forceHTTPS" yaml:"ForceHTTPS"`
+
+	// HSTS, when set, adds a Strict-Transport-Security header to every
+	// HTTPS response the Engine issues a redirect for. Only meaningful
+	// together with ForceHTTPS.
+	HSTS *HSTSConfig `json:"hsts" yaml:"HSTS"`
+
+	// ResponseHeaders are set on every response that passes through the
+	// Engine, regardless of which rule (if any) matched. Per-rule
+	// set/add/remove-header and set/remove-cookie directives can be attached
+	// to a `RedirectMatch` line instead, see `parseHeaderOp`.
+	ResponseHeaders map[string]string `json:"responseHeaders" yaml:"ResponseHeaders"`
+
+	// AccessLog, when set, attaches a JSON-encoding structured `Logger`
+	// (see `SetStructuredLogger`, which lists the requests it records) that
+	// writes one event per recorded request to the given file path, or to
+	// "stdout"/"stderr".
+	AccessLog string `json:"accessLog" yaml:"AccessLog"`
+
 	// Debug to enable debug log.Printf messages.
 	Debug bool `json:"debug" yaml:"Debug"`
 }
 
+// HSTSConfig configures the `Strict-Transport-Security` header
+// `Options.HSTS` adds to HTTPS redirect responses.
+type HSTSConfig struct {
+	// MaxAge is the "max-age" directive, in seconds.
+	MaxAge int `json:"maxAge" yaml:"MaxAge"`
+	// IncludeSubdomains adds the "includeSubDomains" directive.
+	IncludeSubdomains bool `json:"includeSubdomains" yaml:"IncludeSubdomains"`
+	// Preload adds the "preload" directive.
+	Preload bool `json:"preload" yaml:"Preload"`
+}
+
+// headerValue renders the Strict-Transport-Security header value.
+func (c *HSTSConfig) headerValue() string {
+	v := fmt.Sprintf("max-age=%d", c.MaxAge)
+	if c.IncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+	if c.Preload {
+		v += "; preload"
+	}
+
+	return v
+}
+
 // LoadOptions loads rewrite Options from a system file.
+//
+// Besides YAML and JSON, a Netlify-style plain-text "_redirects" file
+// is also supported (detected by the ".txt" extension or a base filename
+// of "_redirects"), see `parseNetlifyRedirectLine` for its grammar.
 func LoadOptions(filename string) (Options, error) {
 	var opts Options
 
@@ -46,6 +108,7 @@ func LoadOptions(filename string) (Options, error) {
 	if index := strings.LastIndexByte(filename, '.'); index > 1 && len(filename)-1 > index {
 		ext = filename[index:]
 	}
+	isRedirectsFile := ext == ".txt" || filepath.Base(filename) == "_redirects"
 
 	f, err := os.Open(filename)
 	if err != nil {
@@ -53,10 +116,14 @@ func LoadOptions(filename string) (Options, error) {
 	}
 	defer f.Close()
 
-	switch ext {
-	case ".yaml", ".yml":
+	switch {
+	case isRedirectsFile:
+		lines, lerr := readRedirectsFileLines(f)
+		err = lerr
+		opts.RedirectMatch = lines
+	case ext == ".yaml" || ext == ".yml":
 		err = yaml.NewDecoder(f).Decode(&opts)
-	case ".json":
+	case ext == ".json":
 		err = json.NewDecoder(f).Decode(&opts)
 	default:
 		return opts, fmt.Errorf("rewrite: unexpected file extension: %q", filename)
@@ -72,11 +139,24 @@ func LoadOptions(filename string) (Options, error) {
 // Engine is the rewrite engine main structure.
 // Navigate through https://github.com/kataras/rewrite/tree/main/_examples for more.
 type Engine struct {
-	redirects []*redirectMatch
-	options   Options
+	// redirects holds the compiled rule set behind an atomic pointer so that
+	// `Reload`/`ReloadFile` (and `LoadWatch`'s fsnotify-driven reloads) can
+	// swap it in without locking out requests being served concurrently.
+	redirects         atomic.Pointer[[]*redirectMatch]
+	adminMu           sync.Mutex // serializes AdminHandler's add/remove rule mutations against each other and against Reload.
+	options           Options
+	proxies           *proxyCache
+	responseHeaderOps []headerOp
+
+	logger           *log.Logger
+	structuredLogger Logger
+	domainValidator  func(string) bool
+}
 
-	logger          *log.Logger
-	domainValidator func(string) bool
+// currentRedirects returns the Engine's rule set as of the last successful
+// `New`/`Reload`/`ReloadFile` call.
+func (e *Engine) currentRedirects() []*redirectMatch {
+	return *e.redirects.Load()
 }
 
 // New returns a new Rewrite Engine based on "opts".
@@ -90,6 +170,7 @@ func New(opts Options) (*Engine, error) {
 		if err != nil {
 			return nil, err
 		}
+		r.raw = line
 		redirects = append(redirects, r)
 	}
 
@@ -98,13 +179,24 @@ func New(opts Options) (*Engine, error) {
 	}
 
 	e := &Engine{
-		options:   opts,
-		redirects: redirects,
+		options:           opts,
+		proxies:           newProxyCache(),
+		responseHeaderOps: responseHeaderOpsFromMap(opts.ResponseHeaders),
 		domainValidator: func(root string) bool {
 			return !strings.HasSuffix(root, localhost)
 		},
 		logger: log.New(os.Stderr, "rewrite: ", log.LstdFlags),
 	}
+	e.redirects.Store(&redirects)
+
+	if opts.AccessLog != "" {
+		w, err := openAccessLogWriter(opts.AccessLog)
+		if err != nil {
+			return nil, err
+		}
+		e.structuredLogger = NewJSONLogger(w)
+	}
+
 	return e, nil
 }
 
@@ -138,6 +230,53 @@ func (e *Engine) SetLogger(logger *log.Logger) *Engine {
 	return e
 }
 
+// SetStructuredLogger attaches a structured access/redirect `Logger` to the
+// Rewrite Engine, replacing any logger set through `Options.AccessLog`.
+// Unlike `SetLogger`, it records regardless of `Options.Debug`: one event per
+// rule redirect, rewrite, proxy or fall-through request. The ForceHTTPS and
+// PrimarySubdomain canonical redirects are not recorded.
+func (e *Engine) SetStructuredLogger(logger Logger) *Engine {
+	e.structuredLogger = logger
+	return e
+}
+
+// Reload re-parses "opts.RedirectMatch" and atomically swaps the compiled
+// rule set in place of the Engine's current one, so in-flight requests keep
+// being served against a consistent rule set. On a parse error, the
+// previous rule set is left untouched and the error is returned as-is.
+// It shares `adminMu` with `Engine.addRule`/`removeRule`, so a file-triggered
+// reload (see `LoadWatch`) can't race a concurrent `AdminHandler` mutation
+// and silently lose one of the two updates.
+// See `ReloadFile` and `LoadWatch` for reloading from a file.
+func (e *Engine) Reload(opts Options) error {
+	redirects := make([]*redirectMatch, 0, len(opts.RedirectMatch))
+	for _, line := range opts.RedirectMatch {
+		r, err := parseRedirectMatchLine(line)
+		if err != nil {
+			return err
+		}
+		r.raw = line
+		redirects = append(redirects, r)
+	}
+
+	e.adminMu.Lock()
+	defer e.adminMu.Unlock()
+
+	e.redirects.Store(&redirects)
+	return nil
+}
+
+// ReloadFile decodes "filename", the same way `LoadOptions` does,
+// and calls `Reload` with the result.
+func (e *Engine) ReloadFile(filename string) error {
+	opts, err := LoadOptions(filename)
+	if err != nil {
+		return err
+	}
+
+	return e.Reload(opts)
+}
+
 func (e *Engine) debugf(format string, args ...interface{}) {
 	if e.options.Debug {
 		e.logger.Printf(format, args...)
@@ -159,92 +298,78 @@ func (e *Engine) Handler(next http.Handler) http.Handler {
 const localhost = "localhost"
 
 func (e *Engine) rewrite(w http.ResponseWriter, r *http.Request, next http.Handler) {
-	if primarySubdomain := e.options.PrimarySubdomain; primarySubdomain != "" {
-		hostport := getHost(r)
-		root := getDomain(hostport)
-
-		e.debugf("Begin request: full host: %s and root domain: %s", hostport, root)
-		// Note:
-		// localhost and 127.0.0.1 are not supported for subdomain rewrite, by purpose,
-		// use a virtual host instead.
-		// GetDomain will return will return localhost or www.localhost
-		// on expected loopbacks.
-		if e.domainValidator(root) {
-			root += getPort(hostport)
-			subdomain := strings.TrimSuffix(hostport, root)
-
-			e.debugf("* Domain is not a loopback, requested subdomain: %s\n", subdomain)
-
-			if subdomain == "" {
-				// we are in root domain, full redirect to its primary subdomain.
-				newHost := primarySubdomain + root
-				e.debugf("* Redirecting from root domain to: %s\n", newHost)
-				r.Host = newHost
-				r.URL.Host = newHost
-				http.Redirect(w, r, r.URL.String(), http.StatusMovedPermanently)
-				return
-			}
-
-			if subdomain == primarySubdomain {
-				// keep root domain as the Host field inside the next handlers,
-				// for consistently use and
-				// to bypass the subdomain router (`routeHandler`)
-				// do not return, redirects should be respected.
-				rootHost := strings.TrimPrefix(hostport, subdomain)
-				e.debugf("* Request host field was modified to: %s. Proceed without redirection\n", rootHost)
-				// modify those for the next redirects or the route handler.
-				r.Host = rootHost
-				r.URL.Host = rootHost
-			}
-
-			// maybe other subdomain or not at all, let's continue.
-		} else {
-			e.debugf("* Primary subdomain is: %s but redirect response was not sent. Domain is a loopback?\n", primarySubdomain)
+	start := time.Now()
+
+	result := e.resolve(r)
+
+	switch result.kind {
+	case matchForceHTTPS:
+		// Note: HSTS is intentionally not set here. RFC 6797 section 7.2
+		// forbids sending Strict-Transport-Security over a non-secure
+		// channel, and browsers ignore it there anyway - it's only set on
+		// the HTTPS side, see the matchRedirect case below.
+		e.debugf("* ForceHTTPS: redirecting to: %s\n", result.target)
+		applyHeaderOps(w.Header(), e.responseHeaderOps)
+		http.Redirect(w, r, result.target, result.code)
+	case matchPrimarySubdomain:
+		e.debugf("* Redirecting from root domain to: %s\n", result.target)
+		if e.options.ForceHTTPS && e.options.HSTS != nil && r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", e.options.HSTS.headerValue())
 		}
-	}
-
-	for _, rd := range e.redirects {
-		src := r.URL.Path
-		if !rd.isRelativePattern {
-			// don't change the request, use a full redirect.
-			src = getScheme(r) + getHost(r) + r.URL.RequestURI()
+		applyHeaderOps(w.Header(), e.responseHeaderOps)
+		http.Redirect(w, r, result.target, result.code)
+	case matchNoop:
+		result.rule.recordHit()
+		ww := e.wrapWriter(w, result.rule.headerOps)
+		e.debugf("* WARNING: source and target URLs match: %s\n", result.src)
+		next.ServeHTTP(ww, r)
+		e.logSkip(r, start)
+	case matchProxy:
+		rd := result.rule
+		rd.recordHit()
+		ww := e.wrapWriter(w, rd.headerOps)
+		e.debugf("* Proxying: from: %s to: %s\n", result.src, result.target)
+		e.serveProxy(ww, r, result.target)
+		e.logRewrite(r, rd, result.ruleIndex, result.src, result.target, start)
+	case matchRewrite:
+		rd := result.rule
+		u, err := r.URL.Parse(result.target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusMisdirectedRequest)
+			return
 		}
 
-		if target, ok := rd.matchAndReplace(src); ok {
-			if target == src {
-				e.debugf("* WARNING: source and target URLs match: %s\n", src)
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			if rd.noRedirect {
-				u, err := r.URL.Parse(target)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusMisdirectedRequest)
-					return
-				}
-
-				e.debugf("* No redirect: handle request: %s as: %s\n", r.RequestURI, u)
-				r.URL = u
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			if !rd.isRelativePattern {
-				// this performs better, no need to check query or host,
-				// the uri already built.
-				e.debugf("* Full redirect: from: %s to: %s\n", src, target)
-				redirectAbs(w, r, target, rd.code)
-			} else {
-				e.debugf("Path redirect: from: %s to: %s\n", src, target)
-				http.Redirect(w, r, target, rd.code)
-			}
+		rd.recordHit()
+		ww := e.wrapWriter(w, rd.headerOps)
+		e.debugf("* No redirect: handle request: %s as: %s\n", r.RequestURI, u)
+		r.URL = u
+		next.ServeHTTP(ww, r)
+		e.logRewrite(r, rd, result.ruleIndex, result.src, result.target, start)
+	case matchRedirect:
+		rd := result.rule
+		rd.recordHit()
+
+		if e.options.ForceHTTPS && e.options.HSTS != nil && r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", e.options.HSTS.headerValue())
+		}
+		applyHeaderOps(w.Header(), e.responseHeaderOps)
+		applyHeaderOps(w.Header(), rd.headerOps)
 
-			return
+		if !rd.isRelativePattern {
+			// this performs better, no need to check query or host,
+			// the uri already built.
+			e.debugf("* Full redirect: from: %s to: %s\n", result.src, result.target)
+			e.logRedirect(r, rd, result.ruleIndex, result.src, result.target, start)
+			redirectAbs(w, r, result.target, rd.code)
+		} else {
+			e.debugf("Path redirect: from: %s to: %s\n", result.src, result.target)
+			e.logRedirect(r, rd, result.ruleIndex, result.src, result.target, start)
+			http.Redirect(w, r, result.target, rd.code)
 		}
+	default: // matchNone
+		next.ServeHTTP(e.wrapWriter(w, nil), r)
+		e.logSkip(r, start)
 	}
-
-	next.ServeHTTP(w, r)
 }
 
 type redirectMatch struct {
@@ -254,6 +379,35 @@ type redirectMatch struct {
 
 	isRelativePattern bool
 	noRedirect        bool
+
+	// isProxy marks a `proxy` rule: instead of redirecting, the matched
+	// request is transparently forwarded to the resolved "target" upstream.
+	isProxy bool
+
+	// conditions, when not empty, must all match the incoming request
+	// (see `matchesConditions`) before this rule is allowed to apply.
+	// Populated from a rule line's trailing `Key=value` fields, on any of the
+	// grammars `parseRedirectMatchLine` accepts, e.g. `Country=us,ca`.
+	conditions []conditionMatcher
+
+	// headerOps holds the response header/cookie mutations to apply whenever
+	// this rule matches, e.g. `set-header:X-Api-Version=2`.
+	headerOps []headerOp
+
+	// raw is the original `Options.RedirectMatch` line this rule was parsed
+	// from, kept around for `Engine.AdminHandler`'s `GET /rules` listing.
+	raw string
+
+	// hits and lastMatchUnixNano are bumped by `recordHit` on every match,
+	// and surfaced through `Engine.AdminHandler`'s `GET /rules` listing.
+	hits              atomic.Int64
+	lastMatchUnixNano atomic.Int64
+}
+
+// recordHit bumps the rule's hit count and last-match timestamp.
+func (r *redirectMatch) recordHit() {
+	r.hits.Add(1)
+	r.lastMatchUnixNano.Store(time.Now().UnixNano())
 }
 
 func (r *redirectMatch) matchAndReplace(src string) (string, bool) {
@@ -266,20 +420,75 @@ func (r *redirectMatch) matchAndReplace(src string) (string, bool) {
 	return "", false
 }
 
+// matchesConditions reports whether all of the rule's conditions (if any)
+// are satisfied by the incoming request.
+func (r *redirectMatch) matchesConditions(req *http.Request) bool {
+	for _, cond := range r.conditions {
+		if !cond.matches(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseRedirectMatchLine parses a single redirect rule line.
+// Three grammars are supported:
+//
+//  1. The original one: "CODE PATTERN TARGET", e.g. "301 /seo/(.*) /$1".
+//  2. The Netlify-style "_redirects" grammar: "FROM TO [CODE] [Key=value ...]",
+//     e.g. "/blog/:year/:slug /posts/:year-:slug 301 Country=us,ca".
+//  3. The reverse-proxy grammar: "proxy PATTERN UPSTREAM",
+//     e.g. "proxy /api/(.*) http://backend:9000/$1".
+//
+// The Netlify-style grammar is recognized whenever the first field is
+// neither a plain status code nor the "proxy" keyword, so all three can be
+// mixed freely inside `Options.RedirectMatch`.
+// Any extra trailing fields, on any of the three grammars, are parsed by
+// `parseRuleModifiers`: either a `Key=value` condition or a header/cookie
+// directive such as `set-header:X-Api-Version=2`.
+// See `readRedirectsFileLines` for loading a whole "_redirects" file at once.
 func parseRedirectMatchLine(s string) (*redirectMatch, error) {
-	parts := strings.Split(strings.TrimSpace(s), " ")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("redirect match: invalid line: %s", s)
+	parts := strings.Fields(strings.TrimSpace(s))
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("redirect match: empty line")
 	}
 
-	codeStr, pattern, target := parts[0], parts[1], parts[2]
+	if len(parts) >= 3 && isDigits(parts[0]) {
+		return parseLegacyRedirectMatchLine(parts[0], parts[1], parts[2], parts[3:])
+	}
 
-	for i, ch := range codeStr {
-		if !isDigit(ch) {
-			return nil, fmt.Errorf("redirect match: status code digits: %s [%d:%c]", codeStr, i, ch)
-		}
+	if len(parts) >= 3 && strings.EqualFold(parts[0], "proxy") {
+		return parseProxyRedirectLine(parts[1], parts[2], parts[3:])
+	}
+
+	return parseNetlifyRedirectLine(parts)
+}
+
+// parseProxyRedirectLine parses the reverse-proxy grammar:
+// "PATTERN UPSTREAM [modifiers...]", e.g. "/api/(.*) http://backend:9000/$1".
+// "UPSTREAM" is expanded the same way a legacy target is, via
+// `regexp.ReplaceAllString`.
+func parseProxyRedirectLine(pattern, upstream string, extra []string) (*redirectMatch, error) {
+	regex := regexp.MustCompile(pattern)
+
+	v := &redirectMatch{
+		pattern:           regex,
+		target:            upstream,
+		isProxy:           true,
+		isRelativePattern: pattern[0] == '/',
+	}
+
+	conditions, headerOps, err := parseRuleModifiers(extra)
+	if err != nil {
+		return nil, err
 	}
+	v.conditions, v.headerOps = conditions, headerOps
 
+	return v, nil
+}
+
+func parseLegacyRedirectMatchLine(codeStr, pattern, target string, extra []string) (*redirectMatch, error) {
 	code, err := strconv.Atoi(codeStr)
 	if err != nil {
 		// this should not happen, we check abt digit
@@ -300,13 +509,248 @@ func parseRedirectMatchLine(s string) (*redirectMatch, error) {
 		isRelativePattern: pattern[0] == '/', // search by path.
 	}
 
+	conditions, headerOps, err := parseRuleModifiers(extra)
+	if err != nil {
+		return nil, err
+	}
+	v.conditions, v.headerOps = conditions, headerOps
+
+	return v, nil
+}
+
+// netlifyParamPattern matches ":name"-style named placeholders,
+// e.g. the "year" and "slug" in "/blog/:year/:slug".
+var netlifyParamPattern = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// parseNetlifyRedirectLine parses the Netlify-style "_redirects" grammar:
+// "FROM TO [CODE] [Key=value,value2 ...]".
+// The status code defaults to 301 when not given.
+// ":name" placeholders in "FROM" are bound into "TO" and a trailing "*"
+// splat is made available as ":splat".
+func parseNetlifyRedirectLine(parts []string) (*redirectMatch, error) {
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("redirect match: invalid line: %s", strings.Join(parts, " "))
+	}
+
+	from, to := parts[0], parts[1]
+	code := http.StatusMovedPermanently
+
+	rest := parts[2:]
+	if len(rest) > 0 && isDigits(rest[0]) {
+		c, err := strconv.Atoi(rest[0])
+		if err != nil {
+			return nil, fmt.Errorf("redirect match: status code digits: %s: %v", rest[0], err)
+		}
+		code = c
+		rest = rest[1:]
+	}
+
+	conditions, headerOps, err := parseRuleModifiers(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := translateNetlifyPath(from)
+	regex := regexp.MustCompile(pattern)
+	target := translateNetlifyTarget(to)
+
+	v := &redirectMatch{
+		code:              code,
+		pattern:           regex,
+		target:            target,
+		noRedirect:        code <= 0,
+		isRelativePattern: from[0] == '/',
+		conditions:        conditions,
+		headerOps:         headerOps,
+	}
+
 	return v, nil
 }
 
+// translateNetlifyPath turns a Netlify-style path, e.g. "/blog/:year/*",
+// into an anchored regular expression with named groups,
+// e.g. "^/blog/(?P<year>[^/]+)/(?P<splat>.*)$".
+func translateNetlifyPath(path string) string {
+	escaped := regexp.QuoteMeta(path)
+	escaped = strings.ReplaceAll(escaped, `\*`, "(?P<splat>.*)")
+	escaped = netlifyParamPattern.ReplaceAllString(escaped, "(?P<$1>[^/]+)")
+	return "^" + escaped + "$"
+}
+
+// translateNetlifyTarget expands ":name" references (including ":splat")
+// in a Netlify-style target into the "${name}" form `regexp.ReplaceAllString` expects.
+func translateNetlifyTarget(target string) string {
+	return netlifyParamPattern.ReplaceAllString(target, "${$1}")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, ch := range s {
+		if !isDigit(ch) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// conditionMatcher represents a single Netlify-style condition, such as
+// "Country=us,ca" or "Role=admin", attached to a redirect rule.
+type conditionMatcher struct {
+	key    string
+	values []string
+}
+
+// parseConditionMatcher parses a single "Key=value,value2" condition.
+func parseConditionMatcher(s string) (conditionMatcher, error) {
+	key, values, ok := strings.Cut(s, "=")
+	if !ok || key == "" || values == "" {
+		return conditionMatcher{}, fmt.Errorf("redirect match: invalid condition: %s", s)
+	}
+
+	return conditionMatcher{key: key, values: strings.Split(values, ",")}, nil
+}
+
+// parseRuleModifiers splits a rule's trailing fields into conditions
+// (`Key=value,value2`) and header/cookie directives
+// (`set-header:Name=Value`, see `parseHeaderOp`).
+func parseRuleModifiers(fields []string) ([]conditionMatcher, []headerOp, error) {
+	conditions := make([]conditionMatcher, 0, len(fields))
+	headerOps := make([]headerOp, 0, len(fields))
+
+	for _, field := range fields {
+		if op, ok, err := parseHeaderOp(field); err != nil {
+			return nil, nil, err
+		} else if ok {
+			headerOps = append(headerOps, op)
+			continue
+		}
+
+		cond, err := parseConditionMatcher(field)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+
+	return conditions, headerOps, nil
+}
+
+// matches reports whether the request satisfies this condition, resolving
+// well-known keys ("Country", "Language", "Role") against the headers and
+// cookies a fronting proxy or GeoIP middleware is expected to set,
+// falling back to a plain header lookup for custom keys.
+func (c conditionMatcher) matches(r *http.Request) bool {
+	if strings.EqualFold(c.key, "language") {
+		return c.matchesLanguage(r)
+	}
+
+	var actual string
+
+	switch strings.ToLower(c.key) {
+	case "country":
+		// Populated by a GeoIP-aware fronting proxy/CDN.
+		actual = r.Header.Get("X-Country")
+	case "role":
+		if cookie, err := r.Cookie("role"); err == nil {
+			actual = cookie.Value
+		}
+		if actual == "" {
+			actual = r.Header.Get("X-Role")
+		}
+	default:
+		actual = r.Header.Get(c.key)
+	}
+
+	if actual == "" {
+		return false
+	}
+
+	for _, v := range c.values {
+		if strings.EqualFold(actual, v) || strings.HasPrefix(strings.ToLower(actual), strings.ToLower(v)+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesLanguage implements the "language" condition key: unlike the other
+// keys, its actual value is a full `Accept-Language` header (or a
+// "language" cookie as a fallback) - a comma-separated list of tags
+// optionally suffixed with a ";q=" weight, e.g. "fr-FR,en;q=0.5" - not a
+// single value, so each tag is parsed out and checked individually instead
+// of prefix-matching the whole header as one string.
+func (c conditionMatcher) matchesLanguage(r *http.Request) bool {
+	actual := r.Header.Get("Accept-Language")
+	if actual == "" {
+		if cookie, err := r.Cookie("language"); err == nil {
+			actual = cookie.Value
+		}
+	}
+
+	if actual == "" {
+		return false
+	}
+
+	for _, tag := range parseAcceptLanguage(actual) {
+		for _, v := range c.values {
+			if strings.EqualFold(tag, v) || strings.HasPrefix(strings.ToLower(tag), strings.ToLower(v)+"-") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseAcceptLanguage splits an `Accept-Language`-style header value, e.g.
+// "fr-FR,en;q=0.5", into its individual language tags ("fr-FR", "en"),
+// dropping the ";q=" weight from each.
+func parseAcceptLanguage(header string) []string {
+	fields := strings.Split(header, ",")
+	tags := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		tag, _, _ := strings.Cut(strings.TrimSpace(field), ";")
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// readRedirectsFileLines reads a Netlify-style "_redirects" text file,
+// skipping blank lines and "#" comments, and returns the remaining
+// lines as-is for `parseRedirectMatchLine` to parse.
+func readRedirectsFileLines(f *os.File) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("rewrite: scan redirects file: %w", err)
+	}
+
+	return lines, nil
+}
+
 const (
 	sufscheme   = "://"
 	schemeHTTPS = "https"
@@ -0,0 +1,203 @@
+package rewrite
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ServeOptions customizes the `Serve` package-level function.
+type ServeOptions struct {
+	// Options are the rewrite Engine options. ForceHTTPS is always set to
+	// true by `Serve`, since that's the whole point of running it.
+	Options Options
+	// Handler serves HTTPS (and canonicalized HTTP) traffic, wrapped with
+	// the Engine's `Handler` middleware.
+	Handler http.Handler
+
+	// Addr80 overrides the plain HTTP listen address, defaults to ":80".
+	Addr80 string
+	// Addr443 overrides the HTTPS listen address, defaults to ":443".
+	Addr443 string
+
+	// DevCert, when true, mints a self-signed certificate per requested
+	// host on the fly instead of requiring TLSCertFile/TLSKeyFile. Intended
+	// for local development only.
+	DevCert bool
+	// TLSCertFile and TLSKeyFile are the certificate/key pair to serve
+	// HTTPS with, ignored when DevCert is true.
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// Serve runs a plain HTTP listener (":80" by default) and a TLS listener
+// (":443" by default) side by side: every request on the HTTP listener is
+// canonicalized to HTTPS (via `Options.ForceHTTPS`, forced on regardless of
+// "opts.Options"), and "opts.Handler" is wrapped with the rewrite Engine's
+// `Handler` middleware on both. In `DevCert` mode, a self-signed CA mints a
+// certificate per requested host lazily, so HTTPS works out of the box
+// without a fronting proxy or a real certificate.
+// It blocks, returning the first listener error.
+func Serve(opts ServeOptions) error {
+	opts.Options.ForceHTTPS = true
+
+	engine, err := New(opts.Options)
+	if err != nil {
+		return err
+	}
+
+	handler := engine.Handler(opts.Handler)
+
+	addr80 := opts.Addr80
+	if addr80 == "" {
+		addr80 = ":80"
+	}
+	addr443 := opts.Addr443
+	if addr443 == "" {
+		addr443 = ":443"
+	}
+
+	httpsServer := &http.Server{Addr: addr443, Handler: handler}
+	if opts.DevCert {
+		issuer, err := newDevCertIssuer()
+		if err != nil {
+			return fmt.Errorf("rewrite: serve: dev cert: %w", err)
+		}
+		httpsServer.TLSConfig = &tls.Config{GetCertificate: issuer.getCertificate}
+	}
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- http.ListenAndServe(addr80, handler) }()
+	go func() {
+		if opts.DevCert {
+			errCh <- httpsServer.ListenAndServeTLS("", "")
+		} else {
+			errCh <- httpsServer.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
+		}
+	}()
+
+	return <-errCh
+}
+
+// devCertIssuer is a minimal self-signed CA that mints a leaf certificate
+// per requested host name on the fly, for `ServeOptions.DevCert`. Issued
+// certificates are cached for the life of the process.
+type devCertIssuer struct {
+	mu     sync.Mutex
+	byHost map[string]*tls.Certificate
+
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+}
+
+func newDevCertIssuer() (*devCertIssuer, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newCertSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "rewrite dev CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	return &devCertIssuer{
+		byHost: make(map[string]*tls.Certificate),
+		caCert: caCert,
+		caKey:  caKey,
+	}, nil
+}
+
+// getCertificate implements `crypto/tls.Config.GetCertificate`.
+func (d *devCertIssuer) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := hello.ServerName
+	if host == "" {
+		host = "localhost"
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cert, ok := d.byHost[host]; ok {
+		return cert, nil
+	}
+
+	cert, err := d.issue(host)
+	if err != nil {
+		return nil, err
+	}
+	d.byHost[host] = cert
+
+	return cert, nil
+}
+
+func (d *devCertIssuer) issue(host string) (*tls.Certificate, error) {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newCertSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, d.caCert, &leafKey.PublicKey, d.caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, d.caCert.Raw},
+		PrivateKey:  leafKey,
+	}, nil
+}
+
+func newCertSerialNumber() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	return rand.Int(rand.Reader, limit)
+}